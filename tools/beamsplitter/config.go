@@ -0,0 +1,169 @@
+/*
+ * Copyright (C) 2022 The Android Open Source Project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"github.com/gobwas/glob"
+	"gopkg.in/yaml.v3"
+)
+
+// TypeMapping describes how a single C++ type should be translated for a given target
+// language, including the import (if any) that the translated type requires.
+type TypeMapping struct {
+	Cpp    string `yaml:"cpp"`
+	Target string `yaml:"target"`
+	Import string `yaml:"import"`
+	// Jni is the JNI type used to cross the native boundary for this C++ type (e.g.
+	// "jfloatArray" for math::float3). Only populated for the "jni" target.
+	Jni string `yaml:"jni"`
+}
+
+// AnnotationRule matches a struct field by its C++ type or default-value pattern and supplies
+// the annotation (e.g. "@NonNull @Size(min = 3)") to emit above that field. Rules are evaluated
+// in file order and the first match wins, mirroring the switch statement it replaces. DefaultEquals
+// and DefaultContains mirror that switch's own mix of exact (`field.DefaultValue == "nullptr"`)
+// and substring (`strings.Contains(field.DefaultValue, "::")`) comparisons; use whichever one the
+// case being reproduced actually used.
+type AnnotationRule struct {
+	Type            string `yaml:"type"`
+	DefaultEquals   string `yaml:"default_equals"`
+	DefaultContains string `yaml:"default_contains"`
+	Annotation      string `yaml:"annotation"`
+}
+
+// CustomFlagHandler supplies the type and default-value rendering for a field tagged with a
+// CustomFlags entry (e.g. "java_float"), replacing the inline special-casing that used to live
+// in the java_type/java_value template funcs.
+type CustomFlagHandler struct {
+	Type           string `yaml:"type"`
+	FirstComponent bool   `yaml:"first_component"`
+}
+
+// Route maps a glob pattern over parse.TypeDefinition names to the destination a matching
+// definition should be appended to: which file, which marked section within that file (only
+// needed when a file has more than one insertion point), and which package it belongs to.
+type Route struct {
+	Match   string `yaml:"match"`
+	File    string `yaml:"file"`
+	Section string `yaml:"section"`
+	Package string `yaml:"package"`
+}
+
+// Target holds everything needed to generate one target language from a parsed C++ header:
+// where its text/template lives on disk and the mapping tables that drive the custom template
+// funcs (docblock, annotation, java_type, java_value, ...).
+type Target struct {
+	Name     string `yaml:"name"`
+	Template string `yaml:"template"`
+	// Package is the Java/Kotlin package generated classes live in, e.g.
+	// "com.google.android.filament". The JNI target uses it to mangle native method names.
+	Package string `yaml:"package"`
+	// DocFormat selects how doc comments are rendered for this target; see renderDoc.
+	DocFormat   DocFormat                    `yaml:"doc_format"`
+	TypeMap     []TypeMapping                `yaml:"type_map"`
+	Annotations []AnnotationRule             `yaml:"annotations"`
+	CustomFlags map[string]CustomFlagHandler `yaml:"custom_flags"`
+	// Routes maps definition names to destination files; see routeFor and editAll.
+	Routes []Route `yaml:"routes"`
+}
+
+// routeFor returns the first Route whose Match glob matches name, used by editAll to decide
+// which file a given struct or enum belongs in.
+func (t *Target) routeFor(name string) (Route, bool) {
+	for _, route := range t.Routes {
+		g, err := glob.Compile(route.Match)
+		if err != nil {
+			log.Fatalf("invalid route pattern %q: %v", route.Match, err)
+		}
+		if g.Match(name) {
+			return route, true
+		}
+	}
+	return Route{}, false
+}
+
+// BeamConfig is the YAML shape beamsplitter.yaml is parsed into: a free-text Source describing
+// what's being translated (the header itself is given with -header), and the list of Targets to
+// emit from it.
+type BeamConfig struct {
+	Source  string   `yaml:"source"`
+	Targets []Target `yaml:"targets"`
+}
+
+// loadConfig reads and parses a beamsplitter YAML config from path.
+func loadConfig(path string) *BeamConfig {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var config BeamConfig
+	if err := yaml.Unmarshal(bytes, &config); err != nil {
+		log.Fatal(err)
+	}
+	return &config
+}
+
+// target looks up a Target by name, exiting fatally if the config doesn't define it.
+func (c *BeamConfig) target(name string) *Target {
+	for i := range c.Targets {
+		if c.Targets[i].Name == name {
+			return &c.Targets[i]
+		}
+	}
+	log.Fatalf("no target named %q in beamsplitter config", name)
+	return nil
+}
+
+// typeMapping returns the TypeMapping for a C++ type, if the target's config has one.
+func (t *Target) typeMapping(cppType string) (TypeMapping, bool) {
+	for _, mapping := range t.TypeMap {
+		if mapping.Cpp == cppType {
+			return mapping, true
+		}
+	}
+	return TypeMapping{}, false
+}
+
+// importFor returns the import statement a C++ type's mapping requires, if any.
+func (t *Target) importFor(cppType string) (string, bool) {
+	mapping, ok := t.typeMapping(cppType)
+	if !ok || mapping.Import == "" {
+		return "", false
+	}
+	return mapping.Import, true
+}
+
+// annotationFor returns the first AnnotationRule that matches the given type/default-value
+// pair, or "" if none apply.
+func (t *Target) annotationFor(fieldType string, defaultValue string) string {
+	for _, rule := range t.Annotations {
+		if rule.Type != "" && rule.Type == fieldType {
+			return rule.Annotation
+		}
+		if rule.DefaultEquals != "" && defaultValue == rule.DefaultEquals {
+			return rule.Annotation
+		}
+		if rule.DefaultContains != "" && strings.Contains(defaultValue, rule.DefaultContains) {
+			return rule.Annotation
+		}
+	}
+	return ""
+}
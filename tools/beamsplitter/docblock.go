@@ -0,0 +1,227 @@
+/*
+ * Copyright (C) 2022 The Android Open Source Project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"beamsplitter/parse"
+)
+
+// DocFormat selects how renderDoc turns a Doxygen/Markdown doc comment into the target
+// language's native doc-comment syntax. The driver exposes this as --doc-format=plain|javadoc|kdoc
+// so the same renderDoc pipeline serves the Java and Kotlin targets. The JNI generator has no doc
+// template func, so its output carries no rendered doc comments whatever its doc_format.
+type DocFormat string
+
+const (
+	DocFormatPlain   DocFormat = "plain"
+	DocFormatJavadoc DocFormat = "javadoc"
+	DocFormatKdoc    DocFormat = "kdoc"
+)
+
+var (
+	reCodeBlock = regexp.MustCompile(`(?s)\\code(?:\{[^}]*\})?\s*(.*?)\\endcode`)
+	reParam     = regexp.MustCompile(`^@param\s+(\S+)\s+(.*)$`)
+	reReturn    = regexp.MustCompile(`^@return\s+(.*)$`)
+	reNote      = regexp.MustCompile(`^@note\s+(.*)$`)
+	reRef       = regexp.MustCompile(`\\ref\s+(\S+)`)
+	reBacktick  = regexp.MustCompile("`([^`]+)`")
+	reBullet    = regexp.MustCompile(`^\s*-\s+(.*)$`)
+)
+
+// reCodeBlockPlaceholder returns the sentinel line renderDoc substitutes for the i'th \code
+// block it pulls out of doc, and that the per-line pass below recognizes and passes through
+// verbatim instead of running it through TrimSpace/bullet/@param handling.
+func reCodeBlockPlaceholder(i int) string {
+	return fmt.Sprintf("\x00CODEBLOCK%d\x00", i)
+}
+
+// docIndex is the set of generated type names, letting renderInline turn a "\ref Foo",
+// "\ref Foo#method", or `Foo`/`Foo#method` backtick span into a resolved {@link Foo#method} (or
+// KDoc [Foo.method]) only when Foo is one of the types being generated in this run; anything
+// else is rendered as an ordinary code span (backticks) or left as the bare name (\ref) rather
+// than a dangling link.
+type docIndex map[string]bool
+
+// buildDocIndex collects the name of every definition in definitions, nested ones included, so
+// that cross-references between them can be resolved while rendering.
+func buildDocIndex(definitions []parse.TypeDefinition) docIndex {
+	index := make(docIndex, len(definitions))
+	for _, definition := range definitions {
+		index[definition.Name()] = true
+	}
+	return index
+}
+
+// renderDoc converts a raw Doxygen/Markdown doc comment (as found verbatim in Filament's C++
+// headers) into depth-indented, format-appropriate source: Javadoc HTML for "javadoc", Markdown
+// KDoc for "kdoc", or the original reflow-only behavior for "plain".
+func renderDoc(doc string, format DocFormat, depth int, index docIndex) string {
+	if doc == "" {
+		return ""
+	}
+	indent := strings.Repeat("    ", depth)
+
+	if format == DocFormatPlain {
+		if strings.Count(doc, "\n") > 0 {
+			return strings.ReplaceAll(doc, "\n", "\n"+indent)
+		}
+		return "/**\n" + indent + " * " + doc + "\n" + indent + " */\n" + indent
+	}
+
+	// \code{.cpp} ... \endcode -> a fenced/preformatted code block. Each match is pulled out
+	// and replaced with a single placeholder line, so the line-by-line pass below never sees
+	// (and so can't mangle via TrimSpace, bullet detection, or @param/@return parsing) the code
+	// body itself; the placeholder is swapped back for the rendered block once that pass is done.
+	var codeBlocks []string
+	doc = reCodeBlock.ReplaceAllStringFunc(doc, func(block string) string {
+		body := strings.Trim(reCodeBlock.FindStringSubmatch(block)[1], "\n")
+		var rendered string
+		if format == DocFormatJavadoc {
+			rendered = "<pre>{@code\n" + body + "\n}</pre>"
+		} else {
+			rendered = "```\n" + body + "\n```"
+		}
+		codeBlocks = append(codeBlocks, rendered)
+		return reCodeBlockPlaceholder(len(codeBlocks) - 1)
+	})
+
+	var out []string
+	inList := false
+	closeList := func() {
+		if inList && format == DocFormatJavadoc {
+			out = append(out, "</ul>")
+		}
+		inList = false
+	}
+
+	lines := strings.Split(doc, "\n")
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case strings.HasPrefix(trimmed, "\x00CODEBLOCK") && strings.HasSuffix(trimmed, "\x00"):
+			closeList()
+			var i int
+			fmt.Sscanf(trimmed, "\x00CODEBLOCK%d\x00", &i)
+			out = append(out, strings.Split(codeBlocks[i], "\n")...)
+			continue
+		case trimmed == "":
+			closeList()
+			if format == DocFormatJavadoc {
+				out = append(out, "<p>")
+			} else {
+				out = append(out, "")
+			}
+			continue
+		case reParam.MatchString(trimmed):
+			closeList()
+			m := reParam.FindStringSubmatch(trimmed)
+			out = append(out, "@param "+m[1]+" "+renderInline(m[2], format, index))
+			continue
+		case reReturn.MatchString(trimmed):
+			closeList()
+			m := reReturn.FindStringSubmatch(trimmed)
+			out = append(out, "@return "+renderInline(m[1], format, index))
+			continue
+		case reNote.MatchString(trimmed):
+			closeList()
+			m := reNote.FindStringSubmatch(trimmed)
+			note := renderInline(m[1], format, index)
+			if format == DocFormatJavadoc {
+				out = append(out, "<p><b>Note:</b> "+note)
+			} else {
+				out = append(out, "> **Note:** "+note)
+			}
+			continue
+		case reBullet.MatchString(trimmed):
+			m := reBullet.FindStringSubmatch(trimmed)
+			item := renderInline(m[1], format, index)
+			if format == DocFormatJavadoc {
+				if !inList {
+					out = append(out, "<ul>")
+					inList = true
+				}
+				out = append(out, "<li>"+item+"</li>")
+			} else {
+				out = append(out, "- "+item)
+			}
+			continue
+		default:
+			closeList()
+			out = append(out, renderInline(trimmed, format, index))
+		}
+	}
+	closeList()
+
+	body := strings.Join(out, "\n"+indent+" * ")
+	return "/**\n" + indent + " * " + body + "\n" + indent + " */\n" + indent
+}
+
+// renderInline resolves the inline markup (backtick code spans and \ref cross-references) that
+// can appear within any line of a doc comment, regardless of which block-level construct it's in.
+func renderInline(text string, format DocFormat, index docIndex) string {
+	text = reRef.ReplaceAllStringFunc(text, func(match string) string {
+		return resolveCrossRef(reRef.FindStringSubmatch(match)[1], format, index)
+	})
+	text = reBacktick.ReplaceAllStringFunc(text, func(match string) string {
+		name := reBacktick.FindStringSubmatch(match)[1]
+		if index[baseName(name)] {
+			return resolveCrossRef(name, format, index)
+		}
+		if format == DocFormatJavadoc {
+			return "<code>" + name + "</code>"
+		}
+		return "`" + name + "`"
+	})
+	return text
+}
+
+// baseName strips a "#method" suffix off a \ref or backtick target, leaving just the type name
+// that's looked up in index.
+func baseName(name string) string {
+	if hash := strings.Index(name, "#"); hash > -1 {
+		return name[:hash]
+	}
+	return name
+}
+
+// resolveCrossRef turns "Foo" or "Foo#method" into a {@link Foo#method} (Javadoc) or
+// [Foo.method] (KDoc/plain) cross-reference when Foo is one of the types being generated in this
+// run, or leaves it as the bare name otherwise.
+func resolveCrossRef(name string, format DocFormat, index docIndex) string {
+	typeName, member := name, ""
+	if hash := strings.Index(name, "#"); hash > -1 {
+		typeName, member = name[:hash], name[hash+1:]
+	}
+	if !index[typeName] {
+		return name
+	}
+	if format == DocFormatJavadoc {
+		if member != "" {
+			return "{@link " + typeName + "#" + member + "}"
+		}
+		return "{@link " + typeName + "}"
+	}
+	if member != "" {
+		return "[" + typeName + "." + member + "]"
+	}
+	return "[" + typeName + "]"
+}
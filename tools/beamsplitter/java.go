@@ -19,9 +19,11 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"text/template"
 
@@ -29,67 +31,61 @@ import (
 )
 
 // Returns a templating function that automatically checks for fatal errors. The returned function
-// takes an output stream, a template name to invoke, and a template context object.
-func createJavaCodeGenerator() func(*os.File, string, parse.TypeDefinition) {
+// takes an output stream, a template name to invoke, and a template context object. The mapping
+// tables baked into customExtensions come entirely from target, which is loaded from
+// beamsplitter.yaml, so adding or changing a type mapping never requires touching this file.
+func createJavaCodeGenerator(target *Target, definitions []parse.TypeDefinition) (func(io.Writer, string, parse.TypeDefinition), func() []string) {
+	docFormat := target.DocFormat
+	if docFormat == "" {
+		docFormat = DocFormatJavadoc
+	}
+	index := buildDocIndex(definitions)
+	imports := make(map[string]bool)
+
 	// These template extensions are used to transmogrify C++ symbols and value literals to Java.
 	customExtensions := template.FuncMap{
 		"docblock": func(defn parse.Documented, depth int) string {
-			doc := defn.GetDoc()
-			if doc == "" {
-				return ""
-			}
-			indent := strings.Repeat("    ", depth)
-			if strings.Count(doc, "\n") > 0 {
-				return strings.ReplaceAll(doc, "\n", "\n"+indent)
-			}
-			return "/**\n" + indent + " * " + doc + "\n" + indent + " */\n" + indent
+			return renderDoc(defn.GetDoc(), docFormat, depth, index)
 		},
 		"annotation": func(field parse.StructField, depth int) string {
 			if _, exists := field.CustomFlags["java_float"]; exists {
 				return ""
 			}
-			annotation := ""
-			switch {
-			case field.DefaultValue == "nullptr":
-				annotation = "@Nullable"
-			case field.Type == "math::float2":
-				annotation = "@NonNull @Size(min = 2)"
-			case field.Type == "math::float3" || field.Type == "LinearColor":
-				annotation = "@NonNull @Size(min = 3)"
-			case field.Type == "math::float4" || field.Type == "LinearColorA":
-				annotation = "@NonNull @Size(min = 4)"
-			case strings.Contains(field.DefaultValue, "::"):
-				annotation = "@NonNull"
-			default:
+			annotation := target.annotationFor(field.Type, field.DefaultValue)
+			if annotation == "" {
 				return ""
 			}
 			return annotation + "\n" + strings.Repeat("    ", depth)
 		},
 		"java_type": func(field parse.StructField) string {
-			if _, exists := field.CustomFlags["java_float"]; exists {
-				return " float"
+			if handler, exists := target.CustomFlags["java_float"]; exists {
+				if _, tagged := field.CustomFlags["java_float"]; tagged {
+					return " " + handler.Type
+				}
 			}
-			switch field.Type {
-			case "math::float2", "math::float3", "math::float4", "LinearColor", "LinearColorA":
-				return " float[]"
-			case "bool":
-				return " boolean"
-			case "uint8_t", "uint16_t", "uint32_t":
-				return " int"
+			if mapping, ok := target.typeMapping(field.Type); ok {
+				if imp, ok := target.importFor(field.Type); ok {
+					imports[imp] = true
+				}
+				return " " + mapping.Target
 			}
 			return " " + strings.ReplaceAll(field.Type, "*", "")
 		},
 		"java_value": func(field parse.StructField) string {
-			if _, exists := field.CustomFlags["java_float"]; exists {
-				arrayContents := strings.Trim(field.DefaultValue, " []")
+			if handler, exists := target.CustomFlags["java_float"]; exists {
+				if _, tagged := field.CustomFlags["java_float"]; tagged {
+					arrayContents := strings.Trim(field.DefaultValue, " []")
 
-				// If we're forcing an array to be bound to a flat, then extract the first component
-				// and use that as the default value.
-				if comma := strings.Index(arrayContents, ","); comma > -1 {
-					return " " + arrayContents[:comma]
-				}
+					// If we're forcing an array to be bound to a float, then extract the first
+					// component and use that as the default value.
+					if handler.FirstComponent {
+						if comma := strings.Index(arrayContents, ","); comma > -1 {
+							return " " + arrayContents[:comma]
+						}
+					}
 
-				return " " + arrayContents
+					return " " + arrayContents
+				}
 			}
 			if field.DefaultValue == "nullptr" {
 				return " null"
@@ -105,64 +101,278 @@ func createJavaCodeGenerator() func(*os.File, string, parse.TypeDefinition) {
 	}
 
 	templ := template.New("beamsplitter").Funcs(customExtensions)
-	templ = template.Must(templ.ParseFiles("java.template"))
-	return func(file *os.File, section string, definition parse.TypeDefinition) {
-		err := templ.ExecuteTemplate(file, section, definition)
+	templ = template.Must(templ.ParseFiles(target.Template))
+	generate := func(w io.Writer, section string, definition parse.TypeDefinition) {
+		err := templ.ExecuteTemplate(w, section, definition)
 		if err != nil {
 			log.Fatal(err.Error())
 		}
 	}
+	// takeImports reports every import collected so far by java_type as a sorted, deduped list,
+	// then clears it. editOneJavaFile dry-runs generate once per destination file solely to
+	// populate this set, drains it with takeImports, and splices in whichever imports aren't
+	// already present before doing the real write pass.
+	takeImports := func() []string {
+		out := make([]string, 0, len(imports))
+		for imp := range imports {
+			out = append(out, imp)
+			delete(imports, imp)
+		}
+		sort.Strings(out)
+		return out
+	}
+	return generate, takeImports
+}
+
+func editJava(definitions []parse.TypeDefinition, classname string, folder string, target *Target) {
+	var routed []routedDefinition
+	for _, definition := range definitions {
+		if definition.Parent() != nil {
+			continue
+		}
+		switch definition.(type) {
+		case *parse.StructDefinition, *parse.EnumDefinition:
+			routed = append(routed, routedDefinition{definition, Route{}})
+		}
+	}
+	generate, takeImports := createJavaCodeGenerator(target, definitions)
+	editOneJavaFile(classname, folder, routed, generate, takeImports)
+}
+
+// routedDefinition pairs a top-level struct or enum with the Route that its name matched in the
+// target's configuration, so editAll knows which file and section it belongs in.
+type routedDefinition struct {
+	definition parse.TypeDefinition
+	route      Route
+}
+
+// editAll routes every top-level struct and enum in definitions to its destination file, per the
+// target's Routes config, and writes each destination exactly once. This lets a single
+// beamsplitter invocation regenerate MaterialBuilder.java, IndirectLight.java, Skybox.java, and
+// so on from one parse of the C++ headers, instead of calling editJava once per class.
+func editAll(definitions []parse.TypeDefinition, folder string, target *Target) {
+	byFile := make(map[string][]routedDefinition)
+	var files []string
+
+	for _, definition := range definitions {
+		if definition.Parent() != nil {
+			continue
+		}
+		switch definition.(type) {
+		case *parse.StructDefinition, *parse.EnumDefinition:
+		default:
+			continue
+		}
+		route, ok := target.routeFor(definition.Name())
+		if !ok {
+			log.Fatalf("no route in target %q matches definition %q", target.Name, definition.Name())
+		}
+		if route.Package != "" && route.Package != target.Package {
+			log.Fatalf("route for %q requests package %q but target %q only supports %q",
+				definition.Name(), route.Package, target.Name, target.Package)
+		}
+		if _, seen := byFile[route.File]; !seen {
+			files = append(files, route.File)
+		}
+		byFile[route.File] = append(byFile[route.File], routedDefinition{definition, route})
+	}
+
+	generate, takeImports := createJavaCodeGenerator(target, definitions)
+	for _, classname := range files {
+		editOneJavaFile(classname, folder, byFile[classname], generate, takeImports)
+	}
+}
+
+// spliceMissingImports inserts an "import X;" line for each of imports not already present in
+// lines, right after the file's existing import block (or its package statement, if it has no
+// imports yet). This is how TypeMapping.Import actually reaches the generated file: java_type
+// records an import the first time it maps a field to a type that needs one, and the caller
+// splices in whatever wasn't already there.
+func spliceMissingImports(lines []string, imports []string) []string {
+	var missing []string
+	for _, imp := range imports {
+		statement := "import " + imp + ";"
+		found := false
+		for _, line := range lines {
+			if strings.TrimSpace(line) == statement {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, statement)
+		}
+	}
+	if len(missing) == 0 {
+		return lines
+	}
+
+	insertAt := 0
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "import ") || strings.HasPrefix(trimmed, "package ") {
+			insertAt = i + 1
+		}
+	}
+
+	out := make([]string, 0, len(lines)+len(missing))
+	out = append(out, lines[:insertAt]...)
+	out = append(out, missing...)
+	out = append(out, lines[insertAt:]...)
+	return out
 }
 
-func editJava(definitions []parse.TypeDefinition, classname string, folder string) {
+// sectionMarker returns the marker line text that opens the generated block for a route
+// section. The empty section reuses the bare kCodelineMarker, matching editJava's original
+// single-block behavior; a named section gets its own marker so a file can carry more than one
+// independently regenerated insertion point (e.g. one per peer class in a growing options file).
+func sectionMarker(section string) string {
+	if section == "" {
+		return kCodelineMarker
+	}
+	return kCodelineMarker + ":" + section
+}
+
+// sectionEndMarker closes a named section's generated block. Unlike the default section, which
+// is always the last thing in the file and can simply be truncated and regenerated, a named
+// section may have hand-written code after it, so its regenerated range has to be bounded on
+// both ends.
+func sectionEndMarker(section string) string {
+	return sectionMarker(section) + ":END"
+}
+
+// editOneJavaFile appends the Struct/Enum rendering of each routed definition to classname+".java".
+// Definitions routed to the empty Section share the file's bare kCodelineMarker and everything
+// from it to end-of-file is regenerated, exactly as editJava always has. Definitions routed to a
+// named Section are instead regenerated between that section's begin/end marker pair, leaving
+// the rest of the file untouched, so one file can be split into several independently
+// regenerated blocks. It's shared by editJava (one class, the implicit default section, per
+// invocation) and editAll (one class per routed file, many sections possible, per invocation).
+func editOneJavaFile(classname string, folder string, definitions []routedDefinition, generate func(io.Writer, string, parse.TypeDefinition), takeImports func() []string) {
+	bySection := make(map[string][]routedDefinition)
+	var sections []string
+	for _, routed := range definitions {
+		if _, seen := bySection[routed.route.Section]; !seen {
+			sections = append(sections, routed.route.Section)
+		}
+		bySection[routed.route.Section] = append(bySection[routed.route.Section], routed)
+	}
+
+	// Dry-run every definition routed to this file against a throwaway writer solely to let
+	// java_type populate the shared import set, then drain it before anything real is written.
+	for _, routed := range definitions {
+		switch routed.definition.(type) {
+		case *parse.StructDefinition:
+			generate(io.Discard, "Struct", routed.definition)
+		case *parse.EnumDefinition:
+			generate(io.Discard, "Enum", routed.definition)
+		}
+	}
+	newImports := takeImports()
+
 	path := filepath.Join(folder, classname+".java")
-	var codelines []string
+	var lines []string
 	{
 		sourceFile, err := os.Open(path)
 		if err != nil {
 			log.Fatal(err)
 		}
-		defer sourceFile.Close()
 		lineScanner := bufio.NewScanner(sourceFile)
-		foundMarker := false
-		for lineNumber := 1; lineScanner.Scan(); lineNumber++ {
-			codeline := lineScanner.Text()
-			if strings.Contains(codeline, kCodelineMarker) {
-				foundMarker = true
+		for lineScanner.Scan() {
+			lines = append(lines, lineScanner.Text())
+		}
+		sourceFile.Close()
+	}
+	lines = spliceMissingImports(lines, newImports)
+
+	type bounds struct {
+		section  string
+		beginIdx int
+		endIdx   int // -1 means "truncate to end of file"
+	}
+	// A bare kCodelineMarker is a substring of every named section's marker
+	// (kCodelineMarker+":name"), so the default section's search must rule those out explicitly -
+	// but only against this file's actual named sections, not any colon a human happened to write
+	// into the marker comment (e.g. "CODE-GENERATED BY BEAMSPLITTER: see also Foo").
+	hasMarker := func(line, section string) bool {
+		if !strings.Contains(line, sectionMarker(section)) {
+			return false
+		}
+		if section != "" {
+			return true
+		}
+		for _, other := range sections {
+			if other != "" && strings.Contains(line, sectionMarker(other)) {
+				return false
+			}
+		}
+		return true
+	}
+
+	var ranges []bounds
+	for _, section := range sections {
+		beginIdx := -1
+		for i, line := range lines {
+			if hasMarker(line, section) {
+				beginIdx = i
 				break
 			}
-			codelines = append(codelines, codeline)
 		}
-		if !foundMarker {
-			log.Fatal("Unable to find marker line in Java file.")
+		if beginIdx == -1 {
+			log.Fatalf("Unable to find %q marker line in %s", sectionMarker(section), path)
 		}
+		endIdx := -1
+		for i := beginIdx + 1; i < len(lines); i++ {
+			if strings.Contains(lines[i], sectionEndMarker(section)) {
+				endIdx = i
+				break
+			}
+		}
+		if endIdx == -1 && section != "" {
+			log.Fatalf("Unable to find %q end marker line in %s", sectionEndMarker(section), path)
+		}
+		ranges = append(ranges, bounds{section, beginIdx, endIdx})
 	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].beginIdx < ranges[j].beginIdx })
+
 	file, err := os.Create(path)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer file.Close()
 	defer fmt.Println("Edited " + path)
-	for _, codeline := range codelines {
-		file.WriteString(codeline)
-		file.WriteString("\n")
-	}
-	file.WriteString("    // " + kCodelineMarker + "\n")
 
-	generate := createJavaCodeGenerator()
-
-	for _, definition := range definitions {
-		switch definition.(type) {
-		case *parse.StructDefinition:
-			if definition.Parent() == nil {
-				generate(file, "Struct", definition)
-			}
-		case *parse.EnumDefinition:
-			if definition.Parent() == nil {
-				generate(file, "Enum", definition)
+	writeLines := func(from, to int) {
+		for _, line := range lines[from:to] {
+			file.WriteString(line)
+			file.WriteString("\n")
+		}
+	}
+	writeSection := func(section string) {
+		for _, routed := range bySection[section] {
+			switch routed.definition.(type) {
+			case *parse.StructDefinition:
+				generate(file, "Struct", routed.definition)
+			case *parse.EnumDefinition:
+				generate(file, "Enum", routed.definition)
 			}
 		}
 	}
 
-	file.WriteString("}\n")
+	cursor := 0
+	for _, r := range ranges {
+		writeLines(cursor, r.beginIdx+1)
+		writeSection(r.section)
+		if r.endIdx == -1 {
+			file.WriteString("}\n")
+			takeImports()
+			return
+		}
+		cursor = r.endIdx
+	}
+	writeLines(cursor, len(lines))
+	// The real write pass above re-populates imports via java_type just like the dry run did;
+	// drain it here too so editAll's single shared generator starts the next file's dry run
+	// clean instead of carrying this file's imports into it.
+	takeImports()
 }
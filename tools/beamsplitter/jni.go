@@ -0,0 +1,123 @@
+/*
+ * Copyright (C) 2022 The Android Open Source Project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"beamsplitter/parse"
+)
+
+// Unlike the Java and Kotlin classes, JNI glue is entirely machine-generated: there is no
+// hand-written half of the file to preserve, so generateJni overwrites classname+".cpp" outright
+// instead of editing around a kCodelineMarker.
+func createJniCodeGenerator(target *Target, definitions []parse.TypeDefinition) func(*os.File, string, parse.TypeDefinition) {
+	// enums indexes the names of every enum (nested ones included) in this run, so jni_to_cpp
+	// can tell an enum field apart from an opaque struct/pointer field without guessing from the
+	// type's spelling or its default value.
+	enums := make(map[string]bool)
+	for _, definition := range definitions {
+		if _, ok := definition.(*parse.EnumDefinition); ok {
+			enums[definition.Name()] = true
+		}
+	}
+
+	// These template extensions bridge Java types back to their C++ counterparts.
+	customExtensions := template.FuncMap{
+		"jni_symbol": func(classname string, method string) string {
+			mangledPackage := strings.ReplaceAll(target.Package, ".", "_")
+			return fmt.Sprintf("Java_%s_%s_%s", mangledPackage, classname, method)
+		},
+		"jni_type": func(field parse.StructField) string {
+			if _, exists := target.CustomFlags["java_float"]; exists {
+				if _, tagged := field.CustomFlags["java_float"]; tagged {
+					return "jfloat"
+				}
+			}
+			if mapping, ok := target.typeMapping(field.Type); ok && mapping.Jni != "" {
+				return mapping.Jni
+			}
+			if enums[strings.ReplaceAll(field.Type, "*", "")] {
+				return "jint"
+			}
+			return "jobject"
+		},
+		// jni_to_cpp renders the C++ expression that converts an incoming JNI argument named
+		// "value" back into the type the native struct field expects. The conversion is driven by
+		// the jni target's own type_map (see beamsplitter.yaml) and by the enums index above: a
+		// field mapped to jfloatArray is copied into a new value of its C++ type, an enum field is
+		// static_cast from its ordinal, and anything else is passed through unchanged.
+		"jni_to_cpp": func(field parse.StructField) string {
+			mapping, ok := target.typeMapping(field.Type)
+			if !ok {
+				if enums[strings.ReplaceAll(field.Type, "*", "")] {
+					return fmt.Sprintf("static_cast<%s>(value)", field.Type)
+				}
+				return "value"
+			}
+			if mapping.Jni != "jfloatArray" {
+				return "value"
+			}
+			// jfloatArray fields are backed by a pinned native array that must be released once
+			// the constructor below has copied out of it, or every call leaks it.
+			return fmt.Sprintf(
+				"[&]{ auto* elems = env->GetFloatArrayElements(value, nullptr); "+
+					"%s result(elems); env->ReleaseFloatArrayElements(value, elems, JNI_ABORT); "+
+					"return result; }()",
+				field.Type)
+		},
+	}
+
+	templ := template.New("beamsplitter").Funcs(customExtensions)
+	templ = template.Must(templ.ParseFiles(target.Template))
+	return func(file *os.File, section string, definition parse.TypeDefinition) {
+		err := templ.ExecuteTemplate(file, section, definition)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+	}
+}
+
+func generateJni(definitions []parse.TypeDefinition, classname string, folder string, target *Target) {
+	path := filepath.Join(folder, classname+".cpp")
+	file, err := os.Create(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+	defer fmt.Println("Generated " + path)
+
+	generate := createJniCodeGenerator(target, definitions)
+
+	for _, definition := range definitions {
+		switch definition.(type) {
+		case *parse.StructDefinition:
+			if definition.Parent() == nil {
+				generate(file, "Struct", definition)
+			}
+		case *parse.EnumDefinition:
+			if definition.Parent() == nil {
+				generate(file, "Enum", definition)
+			}
+		}
+	}
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright (C) 2022 The Android Open Source Project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"beamsplitter/parse"
+)
+
+// Returns a templating function that automatically checks for fatal errors, exactly like
+// createJavaCodeGenerator but emitting idiomatic Kotlin: data classes and enum classes instead
+// of POD classes, FloatArray instead of float[], nullable types via "?" instead of @Nullable,
+// and default parameter values synthesized onto the primary constructor.
+func createKotlinCodeGenerator(target *Target, definitions []parse.TypeDefinition) func(*os.File, string, parse.TypeDefinition) {
+	docFormat := target.DocFormat
+	if docFormat == "" {
+		docFormat = DocFormatKdoc
+	}
+	index := buildDocIndex(definitions)
+
+	// These template extensions are used to transmogrify C++ symbols and value literals to Kotlin.
+	customExtensions := template.FuncMap{
+		"kdoc": func(defn parse.Documented, depth int) string {
+			return renderDoc(defn.GetDoc(), docFormat, depth, index)
+		},
+		"kotlin_type": func(field parse.StructField) string {
+			kotlinType := ""
+			if handler, exists := target.CustomFlags["java_float"]; exists {
+				if _, tagged := field.CustomFlags["java_float"]; tagged {
+					kotlinType = handler.Type
+				}
+			}
+			if kotlinType == "" {
+				if mapping, ok := target.typeMapping(field.Type); ok {
+					kotlinType = mapping.Target
+				} else {
+					kotlinType = strings.ReplaceAll(field.Type, "*", "")
+				}
+			}
+			if field.DefaultValue == "nullptr" {
+				kotlinType += "?"
+			}
+			return " " + kotlinType
+		},
+		"kotlin_value": func(field parse.StructField) string {
+			if handler, exists := target.CustomFlags["java_float"]; exists {
+				if _, tagged := field.CustomFlags["java_float"]; tagged {
+					arrayContents := strings.Trim(field.DefaultValue, " []")
+					if handler.FirstComponent {
+						if comma := strings.Index(arrayContents, ","); comma > -1 {
+							return " = " + arrayContents[:comma] + "f"
+						}
+					}
+					return " = " + arrayContents + "f"
+				}
+			}
+			if field.DefaultValue == "nullptr" {
+				return " = null"
+			}
+			value := strings.ReplaceAll(field.DefaultValue, "::", ".")
+			if field.Type == "float" {
+				value += "f"
+			} else if c := len(value); c > 1 && value[0] == '[' && value[c-1] == ']' {
+				value = "floatArrayOf(" + suffixFloatComponents(value[1:c-1]) + ")"
+			}
+			return " = " + value
+		},
+	}
+
+	templ := template.New("beamsplitter").Funcs(customExtensions)
+	templ = template.Must(templ.ParseFiles(target.Template))
+	return func(file *os.File, section string, definition parse.TypeDefinition) {
+		err := templ.ExecuteTemplate(file, section, definition)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+	}
+}
+
+// suffixFloatComponents appends "f" to each comma-separated component of a C++ array default
+// (e.g. "0.5, 0.5, 0.5") so the result is valid Kotlin: floatArrayOf takes Float varargs, which
+// rejects unsuffixed Int/Double literals like the raw C++ ones.
+func suffixFloatComponents(contents string) string {
+	components := strings.Split(contents, ",")
+	for i, component := range components {
+		components[i] = strings.TrimSpace(component) + "f"
+	}
+	return strings.Join(components, ", ")
+}
+
+func editKotlin(definitions []parse.TypeDefinition, classname string, folder string, target *Target) {
+	path := filepath.Join(folder, classname+".kt")
+	var codelines []string
+	{
+		sourceFile, err := os.Open(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer sourceFile.Close()
+		lineScanner := bufio.NewScanner(sourceFile)
+		foundMarker := false
+		for lineScanner.Scan() {
+			codeline := lineScanner.Text()
+			if strings.Contains(codeline, kCodelineMarker) {
+				foundMarker = true
+				break
+			}
+			codelines = append(codelines, codeline)
+		}
+		if !foundMarker {
+			log.Fatal("Unable to find marker line in Kotlin file.")
+		}
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+	defer fmt.Println("Edited " + path)
+	for _, codeline := range codelines {
+		file.WriteString(codeline)
+		file.WriteString("\n")
+	}
+	file.WriteString("    // " + kCodelineMarker + "\n")
+
+	generate := createKotlinCodeGenerator(target, definitions)
+
+	for _, definition := range definitions {
+		switch definition.(type) {
+		case *parse.StructDefinition:
+			if definition.Parent() == nil {
+				generate(file, "Struct", definition)
+			}
+		case *parse.EnumDefinition:
+			if definition.Parent() == nil {
+				generate(file, "Enum", definition)
+			}
+		}
+	}
+
+	file.WriteString("}\n")
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright (C) 2022 The Android Open Source Project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"log"
+	"strings"
+
+	"beamsplitter/parse"
+)
+
+// kCodelineMarker is the comment text editJava/editKotlin/editAll search for to find where a
+// hand-written source file's generated block starts.
+const kCodelineMarker = "CODE-GENERATED BY BEAMSPLITTER"
+
+func main() {
+	header := flag.String("header", "", "C++ header to parse (see parse.TypeDefinition)")
+	configPath := flag.String("config", "beamsplitter.yaml", "path to the beamsplitter YAML config")
+	lang := flag.String("lang", "java", "comma-separated targets to generate, e.g. java,kotlin,jni")
+	docFormat := flag.String("doc-format", "", "override each target's doc_format: plain, javadoc, or kdoc")
+	classname := flag.String("classname", "", "destination class/file name (ignored by targets with routes configured)")
+	folder := flag.String("folder", ".", "output directory")
+	flag.Parse()
+
+	if *header == "" {
+		log.Fatal("-header is required")
+	}
+	definitions := parse.ParseHeader(*header)
+	config := loadConfig(*configPath)
+
+	for _, name := range strings.Split(*lang, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		target := config.target(name)
+		if *docFormat != "" {
+			target.DocFormat = DocFormat(*docFormat)
+		}
+
+		switch name {
+		case "java":
+			if len(target.Routes) > 0 {
+				editAll(definitions, *folder, target)
+			} else {
+				editJava(definitions, *classname, *folder, target)
+			}
+		case "kotlin":
+			editKotlin(definitions, *classname, *folder, target)
+		case "jni":
+			generateJni(definitions, *classname, *folder, target)
+		default:
+			log.Fatalf("unknown target %q", name)
+		}
+	}
+}